@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// AmbientProvider detects whether the current environment can mint a workload
+// identity token without any skaffold.yaml configuration, and exchanges it for
+// a bearer credential for registry. GKE workload identity and AWS IRSA are
+// covered ambiently by the google.Keychain/ecrKeychain entries in the ordered
+// multi-keychain (see keychain.go) instead of living here, since those already
+// perform the real token exchange through their respective cloud SDKs.
+type AmbientProvider interface {
+	// Detect reports whether this provider's token source is available in the
+	// current environment. It must be cheap and side-effect free.
+	Detect(ctx context.Context) bool
+
+	// Token exchanges this provider's ambient credential for a bearer token
+	// usable against registry. It returns an error if registry isn't a target
+	// this provider knows how to exchange for.
+	Token(ctx context.Context, registry string) (string, error)
+}
+
+// ambientProviders is the ordered list of providers tried when resolving a
+// registry authenticator. The first provider that detects its environment
+// and successfully exchanges a token wins.
+var ambientProviders = []AmbientProvider{
+	&githubActionsProvider{},
+	&spiffeProvider{},
+}
+
+// resolveAmbientCredential tries each ambient provider in order, exchanging
+// the first detected token source for a bearer token scoped to registry.
+func resolveAmbientCredential(ctx context.Context, registry string) (authn.Authenticator, bool) {
+	for _, p := range ambientProviders {
+		if !p.Detect(ctx) {
+			continue
+		}
+		token, err := p.Token(ctx, registry)
+		if err != nil || token == "" {
+			continue
+		}
+		return &authn.Bearer{Token: token}, true
+	}
+	return nil, false
+}
+
+// githubActionsProvider exchanges a GitHub Actions OIDC token
+// (ACTIONS_ID_TOKEN_REQUEST_URL/_TOKEN) for a GCP access token via Workload
+// Identity Federation, for pushing to GCR/Artifact Registry from a GitHub
+// Actions run with no service account key. See
+// https://github.com/google-github-actions/auth for the equivalent action.
+type githubActionsProvider struct{}
+
+func (p *githubActionsProvider) Detect(ctx context.Context) bool {
+	return os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" &&
+		os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN") != "" &&
+		os.Getenv("GOOGLE_WORKLOAD_IDENTITY_AUDIENCE") != ""
+}
+
+func (p *githubActionsProvider) Token(ctx context.Context, registry string) (string, error) {
+	if !isGCPRegistry(registry) {
+		return "", fmt.Errorf("github actions ambient provider only supports GCR/Artifact Registry, got %s", registry)
+	}
+
+	audience := os.Getenv("GOOGLE_WORKLOAD_IDENTITY_AUDIENCE")
+	idToken, err := fetchGithubActionsToken(ctx, audience)
+	if err != nil {
+		return "", fmt.Errorf("fetching GitHub Actions OIDC token: %w", err)
+	}
+
+	return exchangeGithubOIDCForGCPToken(ctx, idToken, audience)
+}
+
+// spiffeProvider fetches a JWT-SVID for registry from the local SPIFFE
+// Workload API, for registries whose auth webhook validates tokens against
+// the cluster's SPIFFE trust domain.
+type spiffeProvider struct{}
+
+func (p *spiffeProvider) Detect(ctx context.Context) bool {
+	return os.Getenv("SPIFFE_ENDPOINT_SOCKET") != ""
+}
+
+func (p *spiffeProvider) Token(ctx context.Context, registry string) (string, error) {
+	return fetchSPIFFESVID(ctx, os.Getenv("SPIFFE_ENDPOINT_SOCKET"), registry)
+}
+
+// isGCPRegistry reports whether registry is served by GCR or Artifact Registry.
+func isGCPRegistry(registry string) bool {
+	return registry == "gcr.io" ||
+		strings.HasSuffix(registry, ".gcr.io") ||
+		strings.HasSuffix(registry, "-docker.pkg.dev")
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/skaffold/testutil"
+)
+
+func TestIsGCPRegistry(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     bool
+	}{
+		{"gcr.io", true},
+		{"us.gcr.io", true},
+		{"us-central1-docker.pkg.dev", true},
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com", false},
+		{"index.docker.io", false},
+	}
+	for _, test := range tests {
+		t.Run(test.registry, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, isGCPRegistry(test.registry))
+		})
+	}
+}
+
+func TestSpiffeSocketAddr(t *testing.T) {
+	tests := []struct {
+		description string
+		path        string
+		want        string
+	}{
+		{"bare path gets unix:// prefix", "/run/spire/agent.sock", "unix:///run/spire/agent.sock"},
+		{"already prefixed is untouched", "unix:///run/spire/agent.sock", "unix:///run/spire/agent.sock"},
+		{"tcp address is untouched", "tcp://localhost:8081", "tcp://localhost:8081"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, spiffeSocketAddr(test.path))
+		})
+	}
+}
+
+func TestGithubActionsProviderDetect(t *testing.T) {
+	p := &githubActionsProvider{}
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_AUDIENCE", "")
+	testutil.CheckDeepEqual(t, false, p.Detect(nil))
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "https://example.com")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "token")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_AUDIENCE", "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider")
+	testutil.CheckDeepEqual(t, true, p.Detect(nil))
+}
+
+func TestGithubActionsProviderTokenRejectsNonGCPRegistry(t *testing.T) {
+	p := &githubActionsProvider{}
+
+	_, err := p.Token(nil, "index.docker.io")
+	testutil.CheckError(t, true, err)
+}
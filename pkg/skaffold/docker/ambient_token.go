@@ -0,0 +1,199 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+const (
+	githubActionsTokenEnvURL   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	githubActionsTokenEnvToken = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+
+	gcpSTSExchangeURL        = "https://sts.googleapis.com/v1/token"
+	gcpIAMCredentialsURLFmt  = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+	gcpCloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+	gcpTokenExchangeGrant    = "urn:ietf:params:oauth:grant-type:token-exchange"
+	gcpAccessTokenType       = "urn:ietf:params:oauth:token-type:access_token"
+	gcpSubjectTokenJWTType   = "urn:ietf:params:oauth:token-type:jwt"
+	gcpServiceAccountEnvName = "GOOGLE_SERVICE_ACCOUNT_EMAIL"
+)
+
+// githubActionsTokenResponse is the payload returned by the GitHub Actions
+// OIDC token endpoint.
+type githubActionsTokenResponse struct {
+	Value string `json:"value"`
+}
+
+// fetchGithubActionsToken exchanges the GitHub Actions runner token for an
+// OIDC token scoped to audience, using ACTIONS_ID_TOKEN_REQUEST_URL/TOKEN.
+func fetchGithubActionsToken(ctx context.Context, audience string) (string, error) {
+	baseURL := os.Getenv(githubActionsTokenEnvURL)
+	separator := "?"
+	if strings.Contains(baseURL, "?") {
+		separator = "&"
+	}
+	reqURL := baseURL + separator + "audience=" + url.QueryEscape(audience)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv(githubActionsTokenEnvToken))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github actions OIDC endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tr githubActionsTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	return tr.Value, nil
+}
+
+// exchangeGithubOIDCForGCPToken trades a GitHub Actions OIDC token for a GCP
+// access token via Workload Identity Federation's STS token exchange, then
+// impersonates GOOGLE_SERVICE_ACCOUNT_EMAIL if set, per
+// https://cloud.google.com/iam/docs/workload-identity-federation-with-other-providers.
+func exchangeGithubOIDCForGCPToken(ctx context.Context, idToken, audience string) (string, error) {
+	federatedToken, err := stsExchangeToken(ctx, audience, idToken)
+	if err != nil {
+		return "", fmt.Errorf("exchanging GitHub OIDC token for a GCP federated token: %w", err)
+	}
+
+	serviceAccount := os.Getenv(gcpServiceAccountEnvName)
+	if serviceAccount == "" {
+		return federatedToken, nil
+	}
+
+	accessToken, err := impersonateGCPServiceAccount(ctx, federatedToken, serviceAccount)
+	if err != nil {
+		return "", fmt.Errorf("impersonating %s: %w", serviceAccount, err)
+	}
+	return accessToken, nil
+}
+
+// stsExchangeToken performs the OAuth 2.0 token exchange (RFC 8693) GCP STS expects.
+func stsExchangeToken(ctx context.Context, audience, subjectToken string) (string, error) {
+	form := url.Values{
+		"grant_type":           {gcpTokenExchangeGrant},
+		"audience":             {audience},
+		"scope":                {gcpCloudPlatformScope},
+		"requested_token_type": {gcpAccessTokenType},
+		"subject_token_type":   {gcpSubjectTokenJWTType},
+		"subject_token":        {subjectToken},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gcpSTSExchangeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GCP STS token exchange returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.AccessToken, nil
+}
+
+// impersonateGCPServiceAccount trades a federated token for a short-lived
+// access token of serviceAccount via the IAM Credentials API.
+func impersonateGCPServiceAccount(ctx context.Context, federatedToken, serviceAccount string) (string, error) {
+	reqBody, err := json.Marshal(struct {
+		Scope []string `json:"scope"`
+	}{Scope: []string{gcpCloudPlatformScope}})
+	if err != nil {
+		return "", err
+	}
+
+	reqURL := fmt.Sprintf(gcpIAMCredentialsURLFmt, serviceAccount)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+federatedToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("IAM Credentials generateAccessToken returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.AccessToken, nil
+}
+
+// fetchSPIFFESVID fetches a JWT-SVID scoped to audience from the Workload API
+// exposed at socketPath.
+func fetchSPIFFESVID(ctx context.Context, socketPath, audience string) (string, error) {
+	svid, err := workloadapi.FetchJWTSVID(ctx,
+		jwtsvid.Params{Audience: audience},
+		workloadapi.WithAddr(spiffeSocketAddr(socketPath)))
+	if err != nil {
+		return "", fmt.Errorf("fetching JWT-SVID for audience %q: %w", audience, err)
+	}
+	return svid.Marshal(), nil
+}
+
+// spiffeSocketAddr normalizes a SPIFFE_ENDPOINT_SOCKET value to the address the
+// Workload API client expects: a bare path gets a unix:// prefix, while unix://
+// and tcp:// addresses are returned unchanged.
+func spiffeSocketAddr(path string) string {
+	if strings.HasPrefix(path, "unix://") || strings.HasPrefix(path, "tcp://") {
+		return path
+	}
+	return "unix://" + path
+}
@@ -17,13 +17,15 @@ limitations under the License.
 package docker
 
 import (
-	"strings"
+	"context"
+	"fmt"
 	"sync"
+	"time"
 
-	"github.com/docker/cli/cli/config"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/google"
+
+	"github.com/GoogleContainerTools/skaffold/pkg/skaffold/instrumentation"
 )
 
 var authenticators = Authenticators{}
@@ -31,11 +33,35 @@ var authenticators = Authenticators{}
 // Authenticators stores an authenticator per registry.
 type Authenticators struct {
 	byRegistry map[string]*lockedAuthenticator
+	registries RegistriesConfig
+	keychains  []authn.Keychain
 	lock       sync.Mutex
 }
 
-// For retrieves the authentiator for a given image reference.
-func (a *Authenticators) For(ref name.Reference) authn.Authenticator {
+// SetRegistriesConfig installs the `build.registries` configuration (credential
+// helpers and an alternate docker config path) used by subsequent calls to For.
+// It does not affect authenticators already resolved and cached for a registry.
+func (a *Authenticators) SetRegistriesConfig(cfg RegistriesConfig) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.registries = cfg
+	a.keychains = nil
+}
+
+// keychainList lazily builds, and caches, the ordered keychains from
+// `build.registries.order` (or DefaultKeychainOrder if unset).
+func (a *Authenticators) keychainList() []authn.Keychain {
+	if a.keychains == nil {
+		a.keychains = buildKeychainList(a.registries.Order)
+	}
+	return a.keychains
+}
+
+// For retrieves the authentiator for a given image reference. softFail, when true,
+// suppresses any error from this registry's configured credential helper (including
+// one that has no credentials for it), falling back through the rest of the chain
+// instead of failing the pull.
+func (a *Authenticators) For(ctx context.Context, ref name.Reference, softFail bool) authn.Authenticator {
 	registry := ref.Context().Registry.Name()
 
 	a.lock.Lock()
@@ -48,7 +74,8 @@ func (a *Authenticators) For(ref name.Reference) authn.Authenticator {
 
 	// Create a new authenticator
 	auth := &lockedAuthenticator{
-		delegate: a.newAuthenticator(ref),
+		registry: registry,
+		delegate: a.newAuthenticator(ctx, ref, softFail),
 	}
 
 	if a.byRegistry == nil {
@@ -59,48 +86,134 @@ func (a *Authenticators) For(ref name.Reference) authn.Authenticator {
 	return auth
 }
 
-// lockedAuthenticator is an authn.Authenticator that can
-// be used safely from multiple go routines.
+// Invalidate clears any cached authorization for registry, forcing the next
+// Authorization/AuthorizationContext call to re-invoke its delegate instead of
+// waiting out the cached credential's expiry. The delegate itself is not
+// re-resolved, so a delegate holding fixed credentials returns the same ones.
+func (a *Authenticators) Invalidate(registry string) {
+	a.lock.Lock()
+	auth, present := a.byRegistry[registry]
+	a.lock.Unlock()
+
+	if present {
+		auth.invalidate()
+	}
+}
+
+// lockedAuthenticator is an authn.Authenticator that can be used safely from
+// multiple go routines. It caches the last resolved AuthConfig until its
+// derived expiry and re-invokes the delegate afterwards. The delegate is chosen
+// once, by For, and is never re-resolved: a delegate that mints tokens itself
+// (e.g. from google.Keychain) returns fresh ones, while fixed credentials such as
+// the authn.Basic from the ECR/ACR keychains or a credential helper come back
+// unchanged for the life of the process.
 type lockedAuthenticator struct {
+	registry string
 	delegate authn.Authenticator
 	lock     sync.Mutex
+
+	cached *authn.AuthConfig
+	expiry time.Time
 }
 
+// Authorization satisfies authn.Authenticator for callers that don't carry a context.
 func (a *lockedAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	return a.AuthorizationContext(context.Background())
+}
+
+// AuthorizationContext resolves the authenticator's credentials. ctx is passed to a
+// delegate that implements ContextAuthenticator, so that cancellation and deadlines
+// reach its subprocess or network calls; any other delegate is called through
+// Authorization() without ctx. A still-valid cached result is returned without re-resolving.
+func (a *lockedAuthenticator) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
 	a.lock.Lock()
-	authorization, err := a.delegate.Authorization()
-	a.lock.Unlock()
-	return authorization, err
+	defer a.lock.Unlock()
+
+	if a.cached != nil && time.Now().Before(a.expiry) {
+		instrumentation.AuthCacheHits.WithLabelValues(a.registry).Inc()
+		return a.cached, nil
+	}
+
+	instrumentation.AuthResolves.WithLabelValues(a.registry).Inc()
+
+	var (
+		auth *authn.AuthConfig
+		err  error
+	)
+	if ctxAuth, ok := a.delegate.(ContextAuthenticator); ok {
+		auth, err = ctxAuth.AuthorizationContext(ctx)
+	} else {
+		auth, err = a.delegate.Authorization()
+	}
+	if err != nil {
+		instrumentation.AuthRefreshFailures.WithLabelValues(a.registry).Inc()
+		return nil, err
+	}
+
+	a.cached = auth
+	a.expiry = authExpiry(auth.Password + auth.IdentityToken + auth.RegistryToken)
+	return auth, nil
+}
+
+// invalidate drops the cached authorization, forcing the next call to re-resolve.
+func (a *lockedAuthenticator) invalidate() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.cached = nil
+	a.expiry = time.Time{}
+}
+
+// ContextAuthenticator is implemented by authenticators that can honor a caller's
+// context, e.g. to cancel an in-flight `gcloud` invocation or cloud token exchange.
+type ContextAuthenticator interface {
+	AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error)
 }
 
 // Create a new authenticator for a given reference
-// 1. If `gcloud` is configured, we use google.NewGcloudAuthenticator(). It is more efficient because it reuses tokens.
-// 2. If something else is configured, we use that authenticator
-// 3. If nothing is configured, we check if `gcloud` can be used
-// 4. Default to anonymous
-func (a *Authenticators) newAuthenticator(ref name.Reference) authn.Authenticator {
+//  1. If a `build.registries` credential helper is configured for this registry, invoke it.
+//  2. If a `build.registries` static config path is configured, load credentials from it.
+//  3. Resolve against the ordered multi-keychain (`build.registries.order`, or
+//     DefaultKeychainOrder: gcloud, docker, ecr, acr, gcr) — gcloud-first by default, the
+//     documented "current gcloud-first behavior" preset. This is also where GKE workload
+//     identity (via the gcr entry's google.Keychain) and AWS IRSA (via ecrKeychain's
+//     default credential chain) are picked up ambiently, with no extra configuration needed.
+//  4. If still unresolved, try an ambient OIDC provider (GitHub Actions, SPIFFE) and
+//     exchange it for a registry token.
+//  5. Default to anonymous.
+func (a *Authenticators) newAuthenticator(ctx context.Context, ref name.Reference, softFail bool) authn.Authenticator {
 	registry := ref.Context().Registry.Name()
 
-	// 1. Use google.NewGcloudAuthenticator() authenticator if `gcloud` is configured
-	cfg, err := config.Load(configDir)
-	if err == nil && cfg.CredentialHelpers[registry] == "gcloud" {
-		if auth, err := google.NewGcloudAuthenticator(); err == nil {
+	// 1. Invoke the configured `docker-credential-<name>` helper binary for this registry
+	if helper, ok := a.registries.Helpers[registry]; ok {
+		auth, err := runCredentialHelper(ctx, helper, registry)
+		switch {
+		case err == nil:
 			return auth
+		case softFail:
+			// fall through to the rest of the chain
+		default:
+			return errAuthenticator{err: fmt.Errorf("running credential helper %q for %s: %w", helper, registry, err)}
 		}
 	}
 
-	// 2. Use whatever `non anonymous` credential helper is configured
-	if auth, _ := authn.DefaultKeychain.Resolve(ref.Context().Registry); auth != authn.Anonymous {
+	// 2. Load credentials from the configured alternate docker config, if any
+	if a.registries.ConfigFile != "" {
+		if auth, ok := authFromConfigFile(a.registries.ConfigFile, registry); ok {
+			return auth
+		}
+	}
+
+	// 3. Resolve against the ordered multi-keychain, propagating ctx to any keychain
+	// (ECR, ACR) whose resolution makes a network call that could otherwise hang.
+	if auth, err := resolveKeychainsContext(ctx, a.keychainList(), ref.Context().Registry); err == nil && auth != authn.Anonymous {
 		return auth
 	}
 
-	// 3. Try gcloud for *.gcr.io
-	if registry == "gcr.io" || strings.HasSuffix(registry, ".gcr.io") {
-		if auth, err := google.NewGcloudAuthenticator(); err == nil {
-			return auth
-		}
+	// 4. Try an ambient OIDC credential, e.g. GitHub Actions or SPIFFE
+	if auth, ok := resolveAmbientCredential(ctx, registry); ok {
+		return auth
 	}
 
-	// 4. Default to anonymous
+	// 5. Default to anonymous
 	return authn.Anonymous
 }
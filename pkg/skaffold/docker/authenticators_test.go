@@ -0,0 +1,208 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/GoogleContainerTools/skaffold/testutil"
+)
+
+// countingAuthenticator returns a fixed result, counting how many times
+// Authorization is called, to assert on caching behavior.
+type countingAuthenticator struct {
+	calls int
+	auth  *authn.AuthConfig
+	err   error
+}
+
+func (c *countingAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	c.calls++
+	return c.auth, c.err
+}
+
+func TestLockedAuthenticatorCachesUntilExpiry(t *testing.T) {
+	delegate := &countingAuthenticator{auth: &authn.AuthConfig{Username: "user", Password: "opaque-secret"}}
+	locked := &lockedAuthenticator{registry: "registry.example.com", delegate: delegate}
+
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.CheckDeepEqual(t, 1, delegate.calls)
+}
+
+func TestLockedAuthenticatorReResolvesExpiredJWT(t *testing.T) {
+	expiredJWT := fakeJWT(time.Now().Add(-time.Hour).Unix())
+	delegate := &countingAuthenticator{auth: &authn.AuthConfig{Password: expiredJWT}}
+	locked := &lockedAuthenticator{registry: "registry.example.com", delegate: delegate}
+
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.CheckDeepEqual(t, 2, delegate.calls)
+}
+
+func TestLockedAuthenticatorInvalidateForcesRefresh(t *testing.T) {
+	delegate := &countingAuthenticator{auth: &authn.AuthConfig{Password: "opaque-secret"}}
+	locked := &lockedAuthenticator{registry: "registry.example.com", delegate: delegate}
+
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	locked.invalidate()
+	if _, err := locked.AuthorizationContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.CheckDeepEqual(t, 2, delegate.calls)
+}
+
+func TestLockedAuthenticatorPropagatesDelegateError(t *testing.T) {
+	wantErr := errors.New("boom")
+	delegate := &countingAuthenticator{err: wantErr}
+	locked := &lockedAuthenticator{registry: "registry.example.com", delegate: delegate}
+
+	_, err := locked.AuthorizationContext(context.Background())
+	testutil.CheckError(t, true, err)
+
+	// A failed resolve must not be cached: the next call retries the delegate.
+	_, _ = locked.AuthorizationContext(context.Background())
+	testutil.CheckDeepEqual(t, 2, delegate.calls)
+}
+
+func TestAuthenticatorsInvalidateUnknownRegistryIsNoop(t *testing.T) {
+	a := &Authenticators{}
+	a.Invalidate("registry.example.com")
+}
+
+// writeFakeConfigFile drops a docker config.json granting basic auth for
+// registry, for tests exercising the `build.registries.configFile` step.
+func writeFakeConfigFile(t *testing.T, registry, username, password string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	auth := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, registry,
+		base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	if err := os.WriteFile(path, []byte(auth), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testRef(t *testing.T, registry string) name.Reference {
+	ref, err := name.ParseReference(registry + "/repo:latest")
+	testutil.CheckError(t, false, err)
+	return ref
+}
+
+func TestNewAuthenticatorCredentialHelperTakesPriorityOverConfigFile(t *testing.T) {
+	const registry = "registry.example.com"
+	writeFakeCredentialHelper(t, "test", `{"Username":"helper-user","Secret":"helper-pass"}`, 0)
+	configPath := writeFakeConfigFile(t, registry, "config-user", "config-pass")
+
+	a := &Authenticators{}
+	a.SetRegistriesConfig(RegistriesConfig{
+		Helpers:    map[string]string{registry: "test"},
+		ConfigFile: configPath,
+	})
+
+	auth := a.For(context.Background(), testRef(t, registry), false)
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, "helper-user", cfg.Username)
+	testutil.CheckDeepEqual(t, "helper-pass", cfg.Password)
+}
+
+func TestNewAuthenticatorConfigFileTakesPriorityOverKeychains(t *testing.T) {
+	const registry = "registry.example.com"
+	configPath := writeFakeConfigFile(t, registry, "config-user", "config-pass")
+
+	a := &Authenticators{}
+	a.SetRegistriesConfig(RegistriesConfig{
+		ConfigFile: configPath,
+		Order:      []string{KeychainDocker},
+	})
+
+	auth := a.For(context.Background(), testRef(t, registry), false)
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, "config-user", cfg.Username)
+	testutil.CheckDeepEqual(t, "config-pass", cfg.Password)
+}
+
+func TestNewAuthenticatorSoftFailFallsThroughOnHelperError(t *testing.T) {
+	const registry = "registry.example.com"
+	writeFakeCredentialHelper(t, "broken", `boom`, 1)
+	configPath := writeFakeConfigFile(t, registry, "config-user", "config-pass")
+
+	a := &Authenticators{}
+	a.SetRegistriesConfig(RegistriesConfig{
+		Helpers:    map[string]string{registry: "broken"},
+		ConfigFile: configPath,
+	})
+
+	auth := a.For(context.Background(), testRef(t, registry), true)
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, "config-user", cfg.Username)
+}
+
+func TestNewAuthenticatorHardFailReturnsErrorOnHelperError(t *testing.T) {
+	const registry = "registry.example.com"
+	writeFakeCredentialHelper(t, "broken", `boom`, 1)
+
+	a := &Authenticators{}
+	a.SetRegistriesConfig(RegistriesConfig{
+		Helpers: map[string]string{registry: "broken"},
+	})
+
+	auth := a.For(context.Background(), testRef(t, registry), false)
+	_, err := auth.Authorization()
+	testutil.CheckError(t, true, err)
+}
+
+func TestNewAuthenticatorFallsBackToAnonymous(t *testing.T) {
+	const registry = "registry.example.com"
+
+	a := &Authenticators{}
+	a.SetRegistriesConfig(RegistriesConfig{
+		Order: []string{KeychainDocker},
+	})
+
+	auth := a.For(context.Background(), testRef(t, registry), false)
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, &authn.AuthConfig{}, cfg)
+}
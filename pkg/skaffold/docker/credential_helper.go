@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// RegistriesConfig is the skaffold.yaml `build.registries` configuration: a way to
+// declare credential sources per project instead of relying on the ambient
+// `~/.docker/config.json`, which isn't the right source of truth in CI runners
+// and restricted sandboxes.
+type RegistriesConfig struct {
+	// Helpers maps a registry hostname to a `docker-credential-<name>` binary on
+	// PATH, invoked with the Docker credential helper protocol.
+	Helpers map[string]string
+
+	// ConfigFile, if set, is consulted before $DOCKER_CONFIG/config.json; registries it
+	// has no credentials for still fall through to the keychains in Order.
+	ConfigFile string
+
+	// Order selects and orders the built-in cloud keychains tried for registries not
+	// covered by Helpers or ConfigFile (see KeychainGcloud, KeychainDocker, KeychainECR,
+	// KeychainACR, KeychainGCR). Defaults to DefaultKeychainOrder.
+	Order []string
+}
+
+// dockerCredentialHelperOutput is the JSON object a `docker-credential-<name> get`
+// helper writes to stdout on success.
+type dockerCredentialHelperOutput struct {
+	Username string
+	Secret   string
+}
+
+// runCredentialHelper invokes `docker-credential-<helper> get`, writing registry to
+// stdin and parsing the {Username, Secret} JSON response, per the protocol documented
+// at https://github.com/docker/docker-credential-helpers.
+func runCredentialHelper(ctx context.Context, helper, registry string) (authn.Authenticator, error) {
+	binary := "docker-credential-" + helper
+
+	cmd := exec.CommandContext(ctx, binary, "get")
+	cmd.Stdin = strings.NewReader(registry)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", binary, err, stderr.String())
+	}
+
+	var out dockerCredentialHelperOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("%s: decoding response: %w", binary, err)
+	}
+	if out.Username == "" && out.Secret == "" {
+		return nil, fmt.Errorf("%s: no credentials for %s", binary, registry)
+	}
+
+	return &authn.Basic{Username: out.Username, Password: out.Secret}, nil
+}
+
+// authFromConfigFile loads registry credentials from an arbitrary docker config
+// JSON file, rather than the ambient $DOCKER_CONFIG/config.json.
+func authFromConfigFile(path, registry string) (authn.Authenticator, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	cf, err := config.LoadFromReader(f)
+	if err != nil {
+		return nil, false
+	}
+
+	auth, err := cf.GetAuthConfig(registry)
+	if err != nil || (auth.Username == "" && auth.Password == "" && auth.IdentityToken == "") {
+		return nil, false
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		Auth:          auth.Auth,
+		IdentityToken: auth.IdentityToken,
+		RegistryToken: auth.RegistryToken,
+	}), true
+}
+
+// errAuthenticator is an authn.Authenticator that always fails with err, used to
+// surface a configuration error lazily at Authorization() time rather than at
+// resolution time, matching how authenticator resolution errors are reported elsewhere.
+type errAuthenticator struct {
+	err error
+}
+
+func (e errAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	return nil, e.err
+}
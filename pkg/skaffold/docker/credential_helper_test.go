@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/GoogleContainerTools/skaffold/testutil"
+)
+
+// writeFakeCredentialHelper drops a `docker-credential-<name>` script on PATH
+// for the life of the test that writes stdout to stdout.
+func writeFakeCredentialHelper(t *testing.T, name, stdout string, exitCode int) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake credential helper script is a POSIX shell script")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "docker-credential-"+name)
+	contents := fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\nexit %d\n", stdout, exitCode)
+	if err := os.WriteFile(script, []byte(contents), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunCredentialHelperSuccess(t *testing.T) {
+	writeFakeCredentialHelper(t, "test", `{"Username":"user","Secret":"pass"}`, 0)
+
+	auth, err := runCredentialHelper(context.Background(), "test", "registry.example.com")
+	testutil.CheckError(t, false, err)
+
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, "user", cfg.Username)
+	testutil.CheckDeepEqual(t, "pass", cfg.Password)
+}
+
+func TestRunCredentialHelperNoCredentials(t *testing.T) {
+	writeFakeCredentialHelper(t, "empty", `{"Username":"","Secret":""}`, 0)
+
+	_, err := runCredentialHelper(context.Background(), "empty", "registry.example.com")
+	testutil.CheckError(t, true, err)
+}
+
+func TestRunCredentialHelperBinaryMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := runCredentialHelper(context.Background(), "does-not-exist", "registry.example.com")
+	testutil.CheckError(t, true, err)
+}
+
+func TestRunCredentialHelperMalformedOutput(t *testing.T) {
+	writeFakeCredentialHelper(t, "bad-json", `not json`, 0)
+
+	_, err := runCredentialHelper(context.Background(), "bad-json", "registry.example.com")
+	testutil.CheckError(t, true, err)
+}
+
+func TestAuthFromConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	contents := `{
+		"auths": {
+			"registry.example.com": {
+				"auth": "dXNlcjpwYXNz"
+			}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, ok := authFromConfigFile(path, "registry.example.com")
+	testutil.CheckDeepEqual(t, true, ok)
+
+	cfg, err := auth.Authorization()
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, "user", cfg.Username)
+	testutil.CheckDeepEqual(t, "pass", cfg.Password)
+}
+
+func TestAuthFromConfigFileNoEntryForRegistry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"auths": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := authFromConfigFile(path, "registry.example.com")
+	testutil.CheckDeepEqual(t, false, ok)
+}
+
+func TestAuthFromConfigFileMissing(t *testing.T) {
+	_, ok := authFromConfigFile(filepath.Join(t.TempDir(), "does-not-exist.json"), "registry.example.com")
+	testutil.CheckDeepEqual(t, false, ok)
+}
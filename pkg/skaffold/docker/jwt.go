@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// defaultAuthExpiry is used when a secret doesn't parse as a JWT with an `exp`
+// claim, e.g. an ECR or static credential-helper secret. It bounds how long a
+// cached authorization is reused before lockedAuthenticator asks its delegate again.
+const defaultAuthExpiry = 55 * time.Minute
+
+// authExpiry returns when a resolved secret should be treated as stale: the
+// `exp` claim if secret parses as a JWT, otherwise now+defaultAuthExpiry.
+func authExpiry(secret string) time.Time {
+	if exp, ok := jwtExpiry(secret); ok {
+		return exp
+	}
+	return time.Now().Add(defaultAuthExpiry)
+}
+
+// jwtExpiry parses secret as a compact JWT and extracts its `exp` claim.
+// It returns false for anything that isn't a three-part, base64url-encoded
+// JWT with a numeric `exp`, which is the common case for non-JWT secrets
+// (basic auth passwords, opaque refresh tokens).
+func jwtExpiry(secret string) (time.Time, bool) {
+	parts := strings.Split(secret, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/skaffold/testutil"
+)
+
+func fakeJWT(exp int64) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+	return header + "." + payload + ".signature"
+}
+
+func TestJwtExpiry(t *testing.T) {
+	tests := []struct {
+		description string
+		secret      string
+		wantOK      bool
+		wantExp     time.Time
+	}{
+		{
+			description: "valid JWT with exp claim",
+			secret:      fakeJWT(1700000000),
+			wantOK:      true,
+			wantExp:     time.Unix(1700000000, 0),
+		},
+		{
+			description: "opaque secret",
+			secret:      "not-a-jwt",
+		},
+		{
+			description: "three parts but not base64",
+			secret:      "a.b.c",
+		},
+		{
+			description: "JWT with no exp claim",
+			secret:      base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".sig",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			exp, ok := jwtExpiry(test.secret)
+			testutil.CheckDeepEqual(t, test.wantOK, ok)
+			if test.wantOK {
+				testutil.CheckDeepEqual(t, test.wantExp, exp)
+			}
+		})
+	}
+}
+
+func TestAuthExpiryFallsBackToDefault(t *testing.T) {
+	before := time.Now().Add(defaultAuthExpiry)
+	got := authExpiry("opaque-secret")
+	after := time.Now().Add(defaultAuthExpiry)
+
+	if got.Before(before) || got.After(after) {
+		t.Fatalf("authExpiry() = %v, want between %v and %v", got, before, after)
+	}
+}
+
+func TestAuthExpiryUsesJWTExp(t *testing.T) {
+	want := time.Unix(1700000000, 0)
+	got := authExpiry(fakeJWT(1700000000))
+	testutil.CheckDeepEqual(t, want, got)
+}
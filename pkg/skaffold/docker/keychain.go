@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+// Keychain names usable in the `build.registries.order` preset, in the order
+// skaffold tries them by default today (gcloud-first).
+const (
+	// KeychainGcloud is the efficient, token-reusing google.NewGcloudAuthenticator(),
+	// used when the ambient docker config names `gcloud` as a registry's credential
+	// helper. This is the real "gcloud-first" behavior the DefaultKeychainOrder preset
+	// preserves; it's distinct from KeychainGCR below.
+	KeychainGcloud = "gcloud"
+	KeychainDocker = "docker"
+	KeychainECR    = "ecr"
+	KeychainACR    = "acr"
+	// KeychainGCR is the generic google.Keychain: gcloud ADC, the GCE/GKE metadata
+	// server (workload identity), or a service account key file, tried regardless of
+	// what (if anything) the ambient docker config names as a credential helper.
+	KeychainGCR = "gcr"
+)
+
+// DefaultKeychainOrder preserves today's gcloud-first behavior for users who
+// don't configure `build.registries.order`.
+var DefaultKeychainOrder = []string{KeychainGcloud, KeychainDocker, KeychainECR, KeychainACR, KeychainGCR}
+
+// ContextKeychain is implemented by keychains whose resolution can make a
+// network call or spawn a subprocess, so that the caller's context (build,
+// deploy, sync) can cancel it instead of letting it hang indefinitely.
+type ContextKeychain interface {
+	ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error)
+}
+
+// buildKeychainList resolves the named keychains, in order, falling back to
+// authn.DefaultKeychain for unrecognized names so a typo in `order` degrades
+// gracefully instead of silently dropping a registry's credential source.
+func buildKeychainList(order []string) []authn.Keychain {
+	if len(order) == 0 {
+		order = DefaultKeychainOrder
+	}
+
+	keychains := make([]authn.Keychain, 0, len(order))
+	for _, name := range order {
+		switch name {
+		case KeychainGcloud:
+			keychains = append(keychains, gcloudCredentialHelperKeychain{})
+		case KeychainGCR:
+			keychains = append(keychains, google.Keychain)
+		case KeychainDocker:
+			keychains = append(keychains, authn.DefaultKeychain)
+		case KeychainECR:
+			keychains = append(keychains, ecrKeychain{})
+		case KeychainACR:
+			keychains = append(keychains, acrKeychain{})
+		default:
+			keychains = append(keychains, authn.DefaultKeychain)
+		}
+	}
+	return keychains
+}
+
+// resolveKeychainsContext tries each keychain in order, propagating ctx to any
+// that implement ContextKeychain. It stops at the first keychain that errors
+// or resolves a non-anonymous authenticator, matching authn.NewMultiKeychain's
+// semantics.
+func resolveKeychainsContext(ctx context.Context, keychains []authn.Keychain, target authn.Resource) (authn.Authenticator, error) {
+	for _, kc := range keychains {
+		var (
+			auth authn.Authenticator
+			err  error
+		)
+		if ctxKc, ok := kc.(ContextKeychain); ok {
+			auth, err = ctxKc.ResolveContext(ctx, target)
+		} else {
+			auth, err = kc.Resolve(target)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if auth != authn.Anonymous {
+			return auth, nil
+		}
+	}
+	return authn.Anonymous, nil
+}
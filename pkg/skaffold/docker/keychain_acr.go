@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// acrRefreshTokenUser is the reserved username ACR expects when the password
+// is an AAD-derived refresh token rather than a service principal secret.
+const acrRefreshTokenUser = "00000000-0000-0000-0000-000000000000"
+
+// acrScope is the AAD scope requested for the token that is exchanged for an
+// ACR refresh token.
+const acrScope = "https://management.azure.com/.default"
+
+// acrKeychain resolves ACR credentials by exchanging an AAD token from
+// azidentity's DefaultAzureCredential chain (e.g. managed identity when running
+// in Azure) for an ACR refresh token via the registry's /oauth2/exchange
+// endpoint. No interactive device-code login is attempted.
+type acrKeychain struct{}
+
+// Resolve satisfies authn.Keychain for callers that don't carry a context.
+func (k acrKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return k.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext resolves ACR credentials, propagating ctx to the AAD token
+// fetch and the /oauth2/exchange call so a caller can cancel a hung request.
+func (acrKeychain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if !strings.HasSuffix(registry, ".azurecr.io") {
+		return authn.Anonymous, nil
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return authn.Anonymous, fmt.Errorf("creating Azure credential: %w", err)
+	}
+
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{acrScope}})
+	if err != nil {
+		return authn.Anonymous, fmt.Errorf("getting AAD token for %s: %w", registry, err)
+	}
+
+	refreshToken, err := exchangeForACRRefreshToken(ctx, registry, token.Token)
+	if err != nil {
+		return authn.Anonymous, err
+	}
+
+	return &authn.Basic{Username: acrRefreshTokenUser, Password: refreshToken}, nil
+}
+
+// exchangeForACRRefreshToken trades an AAD access token for a long-lived ACR
+// refresh token, per https://github.com/Azure/acr/blob/main/docs/AAD-OAuth.md.
+func exchangeForACRRefreshToken(ctx context.Context, registry, aadToken string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {registry},
+		"access_token": {aadToken},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+registry+"/oauth2/exchange",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exchanging AAD token for %s: %w", registry, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ACR token exchange for %s returned status %d", registry, resp.StatusCode)
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding ACR token exchange response for %s: %w", registry, err)
+	}
+	return body.RefreshToken, nil
+}
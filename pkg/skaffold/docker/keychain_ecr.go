@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// ecrRegistry matches an ECR registry hostname, e.g.
+// 123456789012.dkr.ecr.us-east-1.amazonaws.com, capturing its region.
+var ecrRegistry = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com$`)
+
+// ecrKeychain resolves ECR credentials via the AWS default credential chain,
+// which includes IRSA (AWS_WEB_IDENTITY_TOKEN_FILE) for EKS workloads.
+type ecrKeychain struct{}
+
+// Resolve satisfies authn.Keychain for callers that don't carry a context.
+func (k ecrKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return k.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext resolves ECR credentials, propagating ctx to the AWS SDK calls
+// so a caller can cancel a hung STS/ECR round trip.
+func (ecrKeychain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+
+	m := ecrRegistry.FindStringSubmatch(registry)
+	if m == nil {
+		return authn.Anonymous, nil
+	}
+	region := m[1]
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return authn.Anonymous, fmt.Errorf("loading AWS config for %s: %w", registry, err)
+	}
+
+	client := ecr.NewFromConfig(cfg)
+	out, err := client.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return authn.Anonymous, fmt.Errorf("getting ECR authorization token for %s: %w", registry, err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return authn.Anonymous, fmt.Errorf("no ECR authorization data returned for %s", registry)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(*out.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return authn.Anonymous, fmt.Errorf("decoding ECR authorization token for %s: %w", registry, err)
+	}
+
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return authn.Anonymous, fmt.Errorf("malformed ECR authorization token for %s", registry)
+	}
+
+	return &authn.Basic{Username: user, Password: pass}, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+
+	"github.com/GoogleContainerTools/skaffold/testutil"
+)
+
+func TestBuildKeychainListKnownNames(t *testing.T) {
+	keychains := buildKeychainList([]string{KeychainGcloud, KeychainDocker, KeychainECR, KeychainACR, KeychainGCR})
+
+	testutil.CheckDeepEqual(t, 5, len(keychains))
+	testutil.CheckDeepEqual(t, gcloudCredentialHelperKeychain{}, keychains[0])
+	testutil.CheckDeepEqual(t, authn.DefaultKeychain, keychains[1])
+	testutil.CheckDeepEqual(t, ecrKeychain{}, keychains[2])
+	testutil.CheckDeepEqual(t, acrKeychain{}, keychains[3])
+	testutil.CheckDeepEqual(t, google.Keychain, keychains[4])
+}
+
+func TestBuildKeychainListEmptyUsesDefaultOrder(t *testing.T) {
+	keychains := buildKeychainList(nil)
+
+	testutil.CheckDeepEqual(t, len(DefaultKeychainOrder), len(keychains))
+}
+
+func TestBuildKeychainListUnknownNameFallsBackToDockerConfig(t *testing.T) {
+	// A typo in `build.registries.order` must degrade to the ambient docker
+	// config, not silently drop the registry's credential source.
+	keychains := buildKeychainList([]string{"gcrr"})
+
+	testutil.CheckDeepEqual(t, 1, len(keychains))
+	testutil.CheckDeepEqual(t, authn.DefaultKeychain, keychains[0])
+}
+
+// stubKeychain resolves any target to a fixed, non-anonymous basic auth.
+type stubKeychain struct {
+	auth authn.Authenticator
+	err  error
+}
+
+func (s stubKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return s.auth, s.err
+}
+
+func TestResolveKeychainsContextStopsAtFirstNonAnonymous(t *testing.T) {
+	want := &authn.Basic{Username: "user", Password: "pass"}
+	keychains := []authn.Keychain{
+		stubKeychain{auth: authn.Anonymous},
+		stubKeychain{auth: want},
+		stubKeychain{auth: &authn.Basic{Username: "unreached", Password: "unreached"}},
+	}
+
+	ref, err := name.NewRepository("example.com/repo")
+	testutil.CheckError(t, false, err)
+
+	got, err := resolveKeychainsContext(context.Background(), keychains, ref)
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, want, got)
+}
+
+func TestResolveKeychainsContextAllAnonymous(t *testing.T) {
+	keychains := []authn.Keychain{
+		stubKeychain{auth: authn.Anonymous},
+		stubKeychain{auth: authn.Anonymous},
+	}
+
+	ref, err := name.NewRepository("example.com/repo")
+	testutil.CheckError(t, false, err)
+
+	got, err := resolveKeychainsContext(context.Background(), keychains, ref)
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, authn.Anonymous, got)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Skaffold Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Registry auth metrics, labeled by registry hostname, so a long-running
+// `skaffold dev` session can be monitored for credential churn and failures
+// against ECR/ACR/GCR token expiries.
+var (
+	AuthResolves = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "skaffold",
+		Subsystem: "auth",
+		Name:      "resolves_total",
+		Help:      "Number of times a registry authenticator was resolved (cache miss or expiry).",
+	}, []string{"registry"})
+
+	AuthCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "skaffold",
+		Subsystem: "auth",
+		Name:      "cache_hits_total",
+		Help:      "Number of times a cached, unexpired registry authorization was reused.",
+	}, []string{"registry"})
+
+	AuthRefreshFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "skaffold",
+		Subsystem: "auth",
+		Name:      "refresh_failures_total",
+		Help:      "Number of times resolving a registry authorization failed, on first use or after expiry or invalidation.",
+	}, []string{"registry"})
+)